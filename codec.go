@@ -0,0 +1,143 @@
+package jas
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/golang/protobuf/proto"
+	"github.com/vmihailenco/msgpack"
+)
+
+//A Codec marshals and unmarshals the {"data":...,"error":...} envelope and
+//request bodies for a single MIME type. Register one with Config.RegisterCodec
+//to let clients negotiate a response format other than JSON.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	ContentType() string
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (jsonCodec) ContentType() string                        { return "application/json" }
+
+type yamlCodec struct{}
+
+func (yamlCodec) Marshal(v interface{}) ([]byte, error)      { return yaml.Marshal(v) }
+func (yamlCodec) Unmarshal(data []byte, v interface{}) error { return yaml.Unmarshal(data, v) }
+func (yamlCodec) ContentType() string                        { return "application/x-yaml" }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error)      { return msgpack.Marshal(v) }
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error { return msgpack.Unmarshal(data, v) }
+func (msgpackCodec) ContentType() string                        { return "application/x-msgpack" }
+
+//protobufCodec marshals values that implement proto.Message. It's meant for
+//resources that hand it a generated protobuf type rather than the generic
+//{"data":...,"error":...} envelope.
+type protobufCodec struct{}
+
+func (protobufCodec) Marshal(v interface{}) ([]byte, error) {
+	if m, ok := v.(proto.Message); ok {
+		return proto.Marshal(m)
+	}
+	return nil, NewRequestError("value does not implement proto.Message")
+}
+
+func (protobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return NewRequestError("value does not implement proto.Message")
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (protobufCodec) ContentType() string { return "application/x-protobuf" }
+
+//RegisterCodec adds or overrides the codec used for mime, both for writing
+//responses and for Context.Finder when the request's Content-Type matches.
+//"application/json", "application/x-yaml", "application/x-msgpack" and
+//"application/x-protobuf" are registered by default.
+func (c *Config) RegisterCodec(mime string, codec Codec) {
+	c.codecs[strings.ToLower(mime)] = codec
+}
+
+type acceptOption struct {
+	mime string
+	q    float64
+}
+
+//negotiateCodec parses an Accept header with q-values and returns the best
+//registered codec, falling back to the default JSON codec when the header
+//is empty, "*/*", or names nothing that was registered.
+func (router *Router) negotiateCodec(accept string) Codec {
+	return negotiateCodecFrom(router.codecs, accept)
+}
+
+const defaultCodecMime = "application/json"
+
+//defaultCodecs backs the package-level notFound handler, which runs before
+//(or without) a Router in scope and so negotiates against the same four
+//codecs NewRouter registers by default rather than a specific Router's set.
+var defaultCodecs = map[string]Codec{
+	defaultCodecMime:        jsonCodec{},
+	"application/x-yaml":     yamlCodec{},
+	"application/x-msgpack":  msgpackCodec{},
+	"application/x-protobuf": protobufCodec{},
+}
+
+//negotiateCodecFrom is negotiateCodec's logic generalized over an arbitrary
+//codec set, so the package-level default handlers can negotiate without a
+//Router to hold a customized set.
+func negotiateCodecFrom(codecs map[string]Codec, accept string) Codec {
+	accept = strings.TrimSpace(accept)
+	if accept == "" {
+		return codecs[defaultCodecMime]
+	}
+	var options []acceptOption
+	for _, part := range strings.Split(accept, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ";")
+		mime := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		options = append(options, acceptOption{mime, q})
+	}
+	sort.SliceStable(options, func(i, j int) bool { return options[i].q > options[j].q })
+	for _, opt := range options {
+		if opt.mime == "*/*" {
+			return codecs[defaultCodecMime]
+		}
+		if codec, ok := codecs[opt.mime]; ok {
+			return codec
+		}
+	}
+	return codecs[defaultCodecMime]
+}
+
+//codecForContentType returns the codec registered for the request's
+//Content-Type, ignoring any "; charset=..." parameters, falling back to the
+//default JSON codec when the header is empty or names nothing registered.
+func (router *Router) codecForContentType(contentType string) Codec {
+	mime := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if codec, ok := router.codecs[mime]; ok {
+		return codec
+	}
+	return router.codecs[defaultCodecMime]
+}
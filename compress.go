@@ -0,0 +1,63 @@
+package jas
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strings"
+)
+
+//DecoderFactory wraps an encoded io.Reader in a decoder for a
+//Content-Encoding value. It mirrors the signature of gzip.NewReader.
+type DecoderFactory func(io.Reader) (io.ReadCloser, error)
+
+//RegisterDecoder adds or overrides the decoder used for a Content-Encoding
+//value, e.g. "br" or "zstd" backed by a third-party package.
+//"gzip" and "deflate" are registered by default.
+func (c *Config) RegisterDecoder(encoding string, factory DecoderFactory) {
+	c.decoders[strings.ToLower(encoding)] = factory
+}
+
+func gzipDecoder(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func flateDecoder(r io.Reader) (io.ReadCloser, error) {
+	return flate.NewReader(r), nil
+}
+
+//CompressReader returns r.Body wrapped in the decoder registered for the
+//request's Content-Encoding header, or r.Body unchanged if the request
+//is not encoded or the encoding has no registered decoder.
+func (router *Router) CompressReader(r *http.Request) (io.ReadCloser, error) {
+	encoding := strings.TrimSpace(r.Header.Get("Content-Encoding"))
+	if encoding == "" {
+		return r.Body, nil
+	}
+	factory, ok := router.decoders[strings.ToLower(encoding)]
+	if !ok {
+		return r.Body, nil
+	}
+	return factory(r.Body)
+}
+
+//respondError writes err to the client through ctx's negotiated codec and
+//gzip writer the same way a successful response would, and runs it through
+//the same onAppError/Log(ctx) plumbing a panic recovered from a resource
+//method does, so ctx.lastErr (and LogEntry.Err) are populated identically
+//either way. Used for failures (decompression, ...) that short-circuit
+//before a resource method runs.
+func respondError(ctx *Context, err AppError) {
+	var response Response
+	response.Error = err.Message()
+	data, _ := ctx.codec.Marshal(response)
+	ctx.Status = err.Status()
+	ctx.responseWriter.WriteHeader(err.Status())
+	n, _ := ctx.writer.Write(data)
+	ctx.written = n
+	if ctx.config.onAppError != nil {
+		ctx.config.onAppError(err, ctx)
+	}
+	go err.Log(ctx)
+}
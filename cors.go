@@ -0,0 +1,115 @@
+package jas
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+//CORSPolicy configures Cross-Origin Resource Sharing, mirroring the
+//gorilla/handlers and rs/cors option set. The zero value allows nothing.
+type CORSPolicy struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           int
+
+	//If set, takes precedence over AllowedOrigins for deciding whether to
+	//allow a given request's Origin header.
+	AllowOriginFunc func(origin string) bool
+}
+
+//Implement this on a resource to override the router's default CORS
+//policy (Config.CORS) for every method on that resource.
+type ResourceWithCORS interface {
+	CORS() CORSPolicy
+}
+
+func (p CORSPolicy) isOriginAllowed(origin string) bool {
+	if p.AllowOriginFunc != nil {
+		return p.AllowOriginFunc(origin)
+	}
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (p CORSPolicy) isDynamic() bool {
+	return p.AllowOriginFunc != nil || len(p.AllowedOrigins) != 1 || p.AllowedOrigins[0] != "*"
+}
+
+//writeOrigin sets Access-Control-Allow-Origin (and Vary: Origin when the
+//decision depends on the request, i.e. origins aren't unconditionally "*")
+//plus Access-Control-Allow-Credentials. It returns false without writing
+//anything if origin isn't allowed.
+func (p CORSPolicy) writeOrigin(header http.Header, origin string) bool {
+	if origin == "" || !p.isOriginAllowed(origin) {
+		return false
+	}
+	if p.isDynamic() {
+		header.Add("Vary", "Origin")
+		header.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		header.Set("Access-Control-Allow-Origin", "*")
+	}
+	if p.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+	return true
+}
+
+//handleCORS applies CORS headers for an actual (non-preflight) request and
+//answers preflight OPTIONS requests directly, filtering
+//Access-Control-Allow-Methods down to what's actually registered for the
+//path. It returns true if the request was a handled preflight and
+//ServeHTTP must stop, without running any middleware or resource method.
+func (router *Router) handleCORS(w http.ResponseWriter, r *http.Request, segments []string) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	if r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != "" {
+		params := map[string]string{}
+		n, ok := router.trie.matchAny(segments, params)
+		if !ok {
+			router.OnNotFound(w, r)
+			return true
+		}
+		methods := n.allowedMethods()
+		policy, ok := representativePolicy(n, methods)
+		if !ok || !policy.writeOrigin(w.Header(), origin) {
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		}
+		w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+		if len(policy.AllowedHeaders) > 0 {
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join(policy.AllowedHeaders, ", "))
+		}
+		if policy.MaxAge > 0 {
+			w.Header().Set("Access-Control-Max-Age", strconv.Itoa(policy.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return true
+	}
+	params := map[string]string{}
+	if n, ok := router.trie.matchAny(segments, params); ok {
+		if policy, ok := n.policies[dispatchMethod(r.Method)]; ok {
+			if policy.writeOrigin(w.Header(), origin) && len(policy.ExposedHeaders) > 0 {
+				w.Header().Set("Access-Control-Expose-Headers", strings.Join(policy.ExposedHeaders, ", "))
+			}
+		}
+	}
+	return false
+}
+
+func representativePolicy(n *node, methods []string) (CORSPolicy, bool) {
+	for _, m := range methods {
+		return n.policies[m], true
+	}
+	return CORSPolicy{}, false
+}
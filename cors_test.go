@@ -0,0 +1,96 @@
+package jas
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func noopHandler(ctx *Context) {}
+
+func newCORSTestRouter() *Router {
+	getPolicy := CORSPolicy{AllowedOrigins: []string{"https://example.com"}, AllowedHeaders: []string{"X-Custom"}}
+	postPolicy := CORSPolicy{AllowedOrigins: []string{"https://example.com"}}
+	trie := newNode()
+	trie.insert("GET", "/widgets/:id", Handler(noopHandler), getPolicy)
+	trie.insert("POST", "/widgets/:id", Handler(noopHandler), postPolicy)
+	return &Router{trie: trie}
+}
+
+func TestHandleCORSPreflightFiltersToRegisteredMethods(t *testing.T) {
+	router := newCORSTestRouter()
+	r := httptest.NewRequest("OPTIONS", "/widgets/1", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+
+	if handled := router.handleCORS(w, r, splitPath("/widgets/1")); !handled {
+		t.Fatal("expected a preflight request to be handled directly")
+	}
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", w.Code)
+	}
+	allowed := w.Header().Get("Access-Control-Allow-Methods")
+	if !containsMethod(allowed, "GET") || !containsMethod(allowed, "POST") {
+		t.Fatalf("expected GET and POST in Access-Control-Allow-Methods, got %q", allowed)
+	}
+	if containsMethod(allowed, "PUT") || containsMethod(allowed, "DELETE") {
+		t.Fatalf("expected only the registered methods, got %q", allowed)
+	}
+}
+
+func TestHandleCORSPreflightRejectsDisallowedOrigin(t *testing.T) {
+	router := newCORSTestRouter()
+	r := httptest.NewRequest("OPTIONS", "/widgets/1", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+
+	if handled := router.handleCORS(w, r, splitPath("/widgets/1")); !handled {
+		t.Fatal("expected a preflight request to still be handled (and rejected) directly")
+	}
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 for a disallowed origin, got %d", w.Code)
+	}
+}
+
+func TestHandleCORSNonPreflightUsesDispatchMethod(t *testing.T) {
+	router := newCORSTestRouter()
+	r := httptest.NewRequest("HEAD", "/widgets/1", nil)
+	r.Header.Set("Origin", "https://example.com")
+	w := httptest.NewRecorder()
+
+	if handled := router.handleCORS(w, r, splitPath("/widgets/1")); handled {
+		t.Fatal("a non-preflight request must not be treated as handled")
+	}
+	if got := w.Header().Get("Access-Control-Allow-Origin"); got != "https://example.com" {
+		t.Fatalf("expected a HEAD request to pick up the GET policy via dispatchMethod, got Allow-Origin %q", got)
+	}
+}
+
+func containsMethod(header, method string) bool {
+	for _, m := range splitCSV(header) {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func splitCSV(s string) []string {
+	var out []string
+	start := 0
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || s[i] == ',' {
+			field := s[start:i]
+			for len(field) > 0 && field[0] == ' ' {
+				field = field[1:]
+			}
+			if field != "" {
+				out = append(out, field)
+			}
+			start = i + 1
+		}
+	}
+	return out
+}
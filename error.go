@@ -89,23 +89,31 @@ func (ie InternalError) Message() string{
 
 func (ie InternalError) Log(context *Context){
 	if context.config.InternalErrorLogger != nil {
-		buf := new(bytes.Buffer)
-		for i := 3; ; i++ {
-			pc, file, line, ok := runtime.Caller(i)
-			if !ok {
-				break
-			}
-			suffix := file[len(file)-10:]
-			if suffix == "me/panic.c" {
-				continue
-			}
-			if suffix == "t/value.go" {
-				break
-			}
-			fmt.Fprintf(buf, StackFormat, file, line, pc)
+		doLog(context.config.InternalErrorLogger, context, ie, captureStack(4))
+	}
+}
+
+//captureStack walks the goroutine's call stack starting skip frames up,
+//formatting each with StackFormat and skipping the runtime's own panic
+//recovery frame. It's shared by InternalError.Log and the router's
+//AccessLogger wiring so both report the same trace for the same panic.
+func captureStack(skip int) string {
+	buf := new(bytes.Buffer)
+	for i := skip; ; i++ {
+		pc, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		}
+		suffix := file[len(file)-10:]
+		if suffix == "me/panic.c" {
+			continue
+		}
+		if suffix == "t/value.go" {
+			break
 		}
-		doLog(context.config.InternalErrorLogger, context, ie, buf.String())
+		fmt.Fprintf(buf, StackFormat, file, line, pc)
 	}
+	return buf.String()
 }
 
 func doLog(logger *log.Logger, context *Context, err error, stack string){
@@ -0,0 +1,110 @@
+package jas
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+)
+
+//LogEntry describes a single completed request, passed to Config.AccessLogger
+//after the resource method (and its middleware chain) has returned.
+type LogEntry struct {
+	RemoteAddr string
+	UserId     int64
+	Method     string
+	URI        string
+	Proto      string
+	Status     int
+	Bytes      int
+	Duration   time.Duration
+	Err        error
+	Stack      string
+	Tags       map[string]interface{}
+}
+
+//AccessLogger receives one LogEntry per request. Set Config.AccessLogger to
+//use it; it is separate from RequestErrorLogger and InternalErrorLogger,
+//which keep writing their Apache-style line for errors either way.
+type AccessLogger interface {
+	Log(entry LogEntry)
+}
+
+//ApacheAccessLogger formats entries like the router's error log line:
+//an Apache combined-log-style line, with the real status in place of the
+//fixed 200 and the duration in place of the stack trace.
+type ApacheAccessLogger struct {
+	Logger *log.Logger
+}
+
+const accessLogFormat = "%v - %d [%v] \"%v %v %v\" %d %d \"%v\" %v\n"
+
+func (l ApacheAccessLogger) Log(entry LogEntry) {
+	errStr := "-"
+	if entry.Err != nil {
+		errStr = entry.Err.Error()
+	}
+	l.Logger.Printf(
+		accessLogFormat,
+		entry.RemoteAddr,
+		entry.UserId,
+		time.Now().Format(timeFormat),
+		entry.Method,
+		entry.URI,
+		entry.Proto,
+		entry.Status,
+		entry.Bytes,
+		errStr,
+		entry.Duration,
+	)
+}
+
+//JSONAccessLogger writes one JSON object per line, ready for ELK/Loki ingestion.
+type JSONAccessLogger struct {
+	Logger *log.Logger
+}
+
+type jsonLogLine struct {
+	RemoteAddr string                 `json:"remote_addr"`
+	UserId     int64                  `json:"user_id"`
+	Method     string                 `json:"method"`
+	URI        string                 `json:"uri"`
+	Proto      string                 `json:"proto"`
+	Status     int                    `json:"status"`
+	Bytes      int                    `json:"bytes"`
+	DurationMs float64                `json:"duration_ms"`
+	Error      string                 `json:"error,omitempty"`
+	Stack      string                 `json:"stack,omitempty"`
+	Tags       map[string]interface{} `json:"tags,omitempty"`
+}
+
+func (l JSONAccessLogger) Log(entry LogEntry) {
+	line := jsonLogLine{
+		RemoteAddr: entry.RemoteAddr,
+		UserId:     entry.UserId,
+		Method:     entry.Method,
+		URI:        entry.URI,
+		Proto:      entry.Proto,
+		Status:     entry.Status,
+		Bytes:      entry.Bytes,
+		DurationMs: float64(entry.Duration) / float64(time.Millisecond),
+		Stack:      entry.Stack,
+		Tags:       entry.Tags,
+	}
+	if entry.Err != nil {
+		line.Error = entry.Err.Error()
+	}
+	data, err := json.Marshal(line)
+	if err != nil {
+		return
+	}
+	l.Logger.Println(string(data))
+}
+
+//LogField attaches a request-scoped field (tenant id, resource id, ...) that
+//will appear in the LogEntry.Tags passed to Config.AccessLogger.
+func (ctx *Context) LogField(key string, val interface{}) {
+	if ctx.logFields == nil {
+		ctx.logFields = map[string]interface{}{}
+	}
+	ctx.logFields[key] = val
+}
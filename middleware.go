@@ -0,0 +1,83 @@
+package jas
+
+import (
+	"reflect"
+	"strings"
+)
+
+//A Handler is the final or intermediate step in a middleware chain.
+//The resource method itself is wrapped as a Handler before any middleware runs.
+type Handler func(*Context)
+
+//A Middleware wraps a Handler with additional behaviour (auth, rate-limiting,
+//metrics, ...) and returns the wrapped Handler.
+type Middleware func(Handler) Handler
+
+//Implement this on a resource to add middleware that only applies to that
+//resource's methods. Global middleware registered with Router.Use runs first,
+//followed by any middleware added by Router.Group, followed by the resource's own.
+type ResourceWithMiddleware interface {
+	Middleware() []Middleware
+}
+
+//SubRouter mounts resources under a shared path prefix with shared middleware.
+//Create one with Router.Group.
+type SubRouter struct {
+	router     *Router
+	prefix     string
+	middleware []Middleware
+}
+
+//Register global middleware. It runs on every request, outermost first,
+//in the order passed across every call to Use.
+//Must be called before the router starts serving requests.
+func (router *Router) Use(mw ...Middleware) {
+	router.globalMiddleware = append(router.globalMiddleware, mw...)
+	router.build()
+}
+
+//Group returns a SubRouter that mounts resources under prefix with mw applied
+//in addition to the router's global middleware.
+func (router *Router) Group(prefix string, mw ...Middleware) *SubRouter {
+	return &SubRouter{router: router, prefix: strings.Trim(prefix, "/"), middleware: mw}
+}
+
+//Mount registers resources under the SubRouter's prefix, the same way
+//resources passed to NewRouter are registered.
+func (sr *SubRouter) Mount(resources ...interface{}) {
+	sr.router.register(sr.prefix, sr.middleware, resources...)
+	sr.router.build()
+}
+
+func chain(base Handler, mw []Middleware) Handler {
+	handler := base
+	for i := len(mw) - 1; i >= 0; i-- {
+		handler = mw[i](handler)
+	}
+	return handler
+}
+
+//build compiles the middleware chain for every registered method and
+//rebuilds the routing trie from scratch. It runs at registration time, at
+//the end of NewRouter and every Router.Use/SubRouter.Mount call, so a
+//Mount forgotten until after the router started serving requests is never
+//silently missing from the trie.
+func (router *Router) build() {
+	router.trie = newNode()
+	for path, entry := range router.methodMap {
+		fields := strings.SplitN(path, " ", 2)
+		httpMethod, pattern := fields[0], fields[1]
+		methodValue := entry.value
+		base := Handler(func(ctx *Context) {
+			methodValue.Call([]reflect.Value{reflect.ValueOf(ctx)})
+		})
+		mw := make([]Middleware, 0, len(router.globalMiddleware)+len(entry.middleware))
+		mw = append(mw, router.globalMiddleware...)
+		mw = append(mw, entry.middleware...)
+		policy := router.Config.CORS
+		if resWithCORS, ok := entry.resource.(ResourceWithCORS); ok {
+			policy = resWithCORS.CORS()
+		}
+		router.trie.insert(httpMethod, pattern, chain(base, mw), policy)
+	}
+}
@@ -0,0 +1,226 @@
+package jas
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//RouteInfo describes a single handled path, as returned by Router.Routes.
+type RouteInfo struct {
+	HttpMethod   string
+	Path         string
+	ResourceType string
+	MethodName   string
+	HasId        bool
+	Gaps         []string
+}
+
+//Routes returns the route table built from the registered resources,
+//sorted the same way HandledPaths is: by "METHOD /path", method first.
+func (router *Router) Routes() []RouteInfo {
+	infos := make([]RouteInfo, 0, len(router.methodMap))
+	for path, entry := range router.methodMap {
+		fields := strings.SplitN(path, " ", 2)
+		infos = append(infos, RouteInfo{
+			HttpMethod:   fields[0],
+			Path:         fields[1],
+			ResourceType: entry.resourceTypeName,
+			MethodName:   entry.methodName,
+			HasId:        entry.hasId,
+			Gaps:         entry.gaps,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].HttpMethod != infos[j].HttpMethod {
+			return infos[i].HttpMethod < infos[j].HttpMethod
+		}
+		return infos[i].Path < infos[j].Path
+	})
+	return infos
+}
+
+//ParamDoc describes one parameter read through Context.Finder by a resource
+//method, for inclusion in the generated OpenAPI document.
+type ParamDoc struct {
+	Name        string
+	In          string //"query" or "path"
+	Type        string //"string", "integer", "boolean" or "number"
+	Format      string //e.g. "uuid", left blank for most parameters
+	Pattern     string //a regex constraint, left blank for most parameters
+	Required    bool
+	Description string
+}
+
+//RouteDoc is what a resource returns from RouteDocs to describe one of its
+//methods for the OpenAPI generator. Everything is optional.
+type RouteDoc struct {
+	Summary     string
+	Description string
+	Parameters  []ParamDoc
+}
+
+//ResourceWithRouteDocs lets a resource describe its own methods for
+//Router.OpenAPI, keyed by the exported Go method name.
+//
+//Parameters are declared explicitly in RouteDocs rather than inferred from
+//struct tags on the method's params: Context.Finder reads query/body values
+//by name at call time, with no struct for a tag to live on, so there is
+//nothing for reflection to walk.
+type ResourceWithRouteDocs interface {
+	RouteDocs() map[string]RouteDoc
+}
+
+//OpenAPIInfo fills the "info" object of the generated OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string
+	Version     string
+	Description string
+}
+
+//OpenAPI walks the registered resources and returns a valid OpenAPI 3.0
+//document describing every route, its path parameters (":id" and gap
+//segments) and, where a resource implements ResourceWithRouteDocs, its
+//declared parameters and summary/description.
+func (router *Router) OpenAPI(info OpenAPIInfo) ([]byte, error) {
+	paths := map[string]map[string]interface{}{}
+	for _, route := range router.Routes() {
+		oapiPath, pathParams := toOpenAPIPath(route.Path, route.Gaps)
+		operation := map[string]interface{}{
+			"operationId": convertName(route.ResourceType) + "_" + convertName(route.MethodName),
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "successful response",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"data":  map[string]interface{}{},
+									"error": map[string]interface{}{"type": "string", "nullable": true},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+		parameters := pathParams
+		if entry, ok := router.methodMap[route.HttpMethod+" "+route.Path]; ok {
+			if describer, ok := entry.resource.(ResourceWithRouteDocs); ok {
+				if doc, ok := describer.RouteDocs()[entry.methodName]; ok {
+					if doc.Summary != "" {
+						operation["summary"] = doc.Summary
+					}
+					if doc.Description != "" {
+						operation["description"] = doc.Description
+					}
+					for _, p := range doc.Parameters {
+						parameters = append(parameters, paramSchema(p))
+					}
+				}
+			}
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if paths[oapiPath] == nil {
+			paths[oapiPath] = map[string]interface{}{}
+		}
+		paths[oapiPath][strings.ToLower(route.HttpMethod)] = operation
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       info.Title,
+			"version":     info.Version,
+			"description": info.Description,
+		},
+		"paths": paths,
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+//toOpenAPIPath rewrites every path-parameter segment ("/:id" as well as
+//gap captures like "/:org", "/{id:uuid}" or "/{slug:[a-z-]+}") to OpenAPI's
+//"{name}" form using the same token parsing the trie matches against, and
+//returns a ParamDoc for each. Two captures sharing a name (most commonly
+//repeated ":id" segments on nested id resources) are disambiguated by
+//suffixing the second and later ones "name1", "name2", ...
+func toOpenAPIPath(path string, gaps []string) (string, []map[string]interface{}) {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	var params []map[string]interface{}
+	seen := map[string]int{}
+	for i, seg := range segments {
+		if !strings.HasPrefix(seg, ":") && !strings.HasPrefix(seg, "{") {
+			continue
+		}
+		baseName, kind, re := parseParamToken(seg)
+		name := baseName
+		if n := seen[baseName]; n > 0 {
+			name = baseName + strconv.Itoa(n)
+		}
+		seen[baseName]++
+		segments[i] = "{" + name + "}"
+		doc := ParamDoc{Name: name, In: "path", Required: true}
+		switch kind {
+		case paramInt:
+			doc.Type = "integer"
+		case paramUUID:
+			doc.Type = "string"
+			doc.Format = "uuid"
+		case paramRegex:
+			doc.Type = "string"
+			doc.Pattern = re.String()
+		default:
+			doc.Type = "string"
+		}
+		params = append(params, paramSchema(doc))
+	}
+	_ = gaps
+	return "/" + strings.Join(segments, "/"), params
+}
+
+func paramSchema(p ParamDoc) map[string]interface{} {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	schema := map[string]interface{}{"type": typ}
+	if p.Format != "" {
+		schema["format"] = p.Format
+	}
+	if p.Pattern != "" {
+		schema["pattern"] = p.Pattern
+	}
+	return map[string]interface{}{
+		"name":        p.Name,
+		"in":          p.In,
+		"required":    p.Required,
+		"description": p.Description,
+		"schema":      schema,
+	}
+}
+
+//Openapi serves the router's generated OpenAPI 3 document at
+//GET /openapi/json, following the same name-conversion rules as any
+//other resource. Mount it after every other resource so the document
+//reflects what's actually registered:
+//
+//	jas.NewRouter(resources..., &jas.Openapi{Router: router, Info: jas.OpenAPIInfo{...}})
+//
+//Set the Router field to the *jas.Router returned by NewRouter once it
+//exists; a resource can't reference the router that is constructing it.
+type Openapi struct {
+	Router *Router
+	Info   OpenAPIInfo
+}
+
+func (res *Openapi) Json(ctx *Context) {
+	doc, err := res.Router.OpenAPI(res.Info)
+	if err != nil {
+		panic(NewInternalError(err))
+	}
+	ctx.Data = json.RawMessage(doc)
+}
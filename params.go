@@ -0,0 +1,18 @@
+package jas
+
+import "strconv"
+
+//Param returns the value captured for a named path segment, e.g. ":org" in
+//a pattern like "orgs/:org/repos/:repo/issues/:num", or "" if there is no
+//such capture on the matched route.
+func (ctx *Context) Param(name string) string {
+	return ctx.params[name]
+}
+
+//ParamInt parses the named path segment as a base-10 integer. It returns 0
+//if there is no such capture or it does not parse as an integer; resources
+//that declare a typed "{name:int}" segment can rely on it always parsing.
+func (ctx *Context) ParamInt(name string) int64 {
+	n, _ := strconv.ParseInt(ctx.params[name], 10, 64)
+	return n
+}
@@ -11,6 +11,7 @@ If resource method name ends with "Id", an integer path segment is added to the
 You can get the Id value directly in *jas.Context field.
 
 If resource implements ResourceWithGap interface, the handled path will has gap segments between resource name and method name.
+Gap segments may contain ":name" captures (e.g. "orgs/:org/repos/:repo"), readable in the method via *jas.Context.Param.
 
 HTTP "POST", "PUT", "DELETE" will be routed to resource methods with "Post", "Put", "Delete" prefix.
 HTTP "GET" will be routed to resource methods with or without "Get" prefix.
@@ -49,17 +50,33 @@ import (
 	"os"
 	"compress/gzip"
 	"sort"
-	"encoding/json"
 	"bytes"
 	"io"
 	"strconv"
+	"time"
 )
 
 var WordSeparator = "_"
 
+//methodEntry holds a registered resource method together with the
+//middleware contributed by the resource and the Group/Mount it was
+//registered through, plus enough bookkeeping to describe the route
+//for HandledPaths/Routes/OpenAPI.
+type methodEntry struct {
+	value            reflect.Value
+	middleware       []Middleware
+	resource         interface{}
+	resourceTypeName string
+	methodName       string
+	hasId            bool
+	gaps             []string
+}
+
 type Router struct {
-	methodMap map[string]reflect.Value
-	gapsMap   map[string][]string
+	methodMap        map[string]methodEntry
+	globalMiddleware []Middleware
+	trie             *node
+	userOnAppError   func(AppError, *Context)
 	*Config
 }
 
@@ -75,12 +92,10 @@ type Config struct {
 	//Defaults to "/".
 	BasePath string
 
-	//Handle Cross-origin Resource Sharing.
-	//It accept request and response header parameter.
-	//return true to go on handle the request, return false to stop handling and response with header only.
-	//Defaults to nil
-	//You can set it to AllowCORS function to allow all CORS request.
-	HandleCORS func (*http.Request, http.Header) bool
+	//Default Cross-Origin Resource Sharing policy, applied to every route
+	//whose resource does not implement ResourceWithCORS.
+	//Defaults to the zero value, which allows no cross-origin request.
+	CORS CORSPolicy
 
 	//gzip is disabled by default. set true to enable it
 	EnableGzip bool
@@ -91,12 +106,19 @@ type Config struct {
 	//log to standard err by default.
 	InternalErrorLogger *log.Logger
 
-	//If set, it will be called after recovered from panic.
-	//Do time consuming work in the function will not increase response time because it runs in its own goroutine.
-	OnAppError func (AppError, *Context)
+	//If set, every request that matches a route is logged through it once
+	//handling completes, success or not, including status, bytes written and
+	//latency. Requests answered as not found or as CORS preflights are not logged.
+	//Defaults to nil. Ship ApacheAccessLogger or JSONAccessLogger, or
+	//implement AccessLogger yourself.
+	AccessLogger AccessLogger
 
-	//If set, it will be called before calling the matched method.
-	BeforeServe func (*Context)
+	//The router's own AppError hook, set by NewRouter: it records the error
+	//(and stack) for AccessLogger and then calls the hook passed to
+	//SetOnAppError, which it reads fresh every time an AppError is recovered
+	//rather than snapshotted at registration time, so calling SetOnAppError
+	//at any point, before or after NewRouter returns, always takes effect.
+	onAppError func (AppError, *Context)
 
 	//If set, the user id can be obtained by *Context.UserId and will be logged on error.
 	//Implementations can be like decode cookie value or token parameter.
@@ -109,12 +131,14 @@ type Config struct {
 	//default function just send `{"data":null,"error":"NotFound"}` with 404 status code.
 	OnNotFound func (http.ResponseWriter, *http.Request)
 
-	//if you do not like the default json format `{"data":...,"error":...}`,
-	//you can define your own write function here.
-	//The io.Writer may be http.ResponseWriter or GzipWriter depends on if gzip is enabled.
-	//The errMessage is of type string or nil, it's not AppError.
-	//it should return the number of bytes has been written.
-	HijackWrite func (io.Writer, *Context) int
+	//If true, a request body whose Content-Encoding is "gzip" or "deflate"
+	//(or any encoding registered with RegisterDecoder) is transparently
+	//decoded before Context.Finder reads it. Defaults to false.
+	EnableRequestDecompression bool
+
+	decoders map[string]DecoderFactory
+
+	codecs map[string]Codec
 }
 
 //Implements http.Handler interface.
@@ -124,25 +148,28 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	rawPath := r.URL.Path[len(router.BasePath):]
-	path, id, segments, gaps := router.resolvePath(r.Method, rawPath)
-	methodValue, ok := router.methodMap[path]
+	segments := splitPath(rawPath)
+	if router.handleCORS(w, r, segments) {
+		return
+	}
+	params := map[string]string{}
+	handler, ok := router.trie.match(dispatchMethod(r.Method), segments, params)
 	if !ok {
 		router.OnNotFound(w, r)
 		return
 	}
+	id, _ := strconv.ParseInt(params["id"], 10, 64)
+	codec := router.negotiateCodec(r.Header.Get("Accept"))
 	ctx := new(Context)
 	ctx.Id = id
 	ctx.pathSegments = segments
 	ctx.Request = r
-	ctx.gaps = gaps
-	ctx.Finder = FinderWithRequest(r)
+	ctx.params = params
+	ctx.codec = codec
 	ctx.ResponseHeader = w.Header()
 	ctx.config = router.Config
 	ctx.responseWriter = w
 	ctx.Status = 200
-	if router.HandleCORS != nil && !router.HandleCORS(r, ctx.ResponseHeader) {
-		return
-	}
 	if router.EnableGzip && strings.Contains(ctx.Header.Get("Accept-Encoding"), "gzip") {
 		gz := gzip.NewWriter(ctx.responseWriter)
 		defer gz.Close()
@@ -155,12 +182,62 @@ func (router *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		ctx.UserId = router.ParseIdFunc(r)
 	}
 	ctx.ResponseHeader.Set("Cache-Control", "no-cache")
-	ctx.ResponseHeader.Set("Content-Type", "application/json; charset=utf-8")
-	defer ctx.deferredResponse()
-	if router.BeforeServe != nil {
-		router.BeforeServe(ctx)
+	ctx.ResponseHeader.Set("Content-Type", codec.ContentType()+"; charset=utf-8")
+	if router.AccessLogger != nil {
+		start := time.Now()
+		defer func() {
+			router.AccessLogger.Log(LogEntry{
+				RemoteAddr: ctx.RemoteAddr,
+				UserId:     ctx.UserId,
+				Method:     ctx.Method,
+				URI:        ctx.RequestURI,
+				Proto:      ctx.Proto,
+				Status:     ctx.Status,
+				Bytes:      ctx.written,
+				Duration:   time.Since(start),
+				Err:        ctx.lastErr,
+				Stack:      ctx.lastStack,
+				Tags:       ctx.logFields,
+			})
+		}()
+	}
+	//Decompression failures are reported through respondError, which goes
+	//through the same RequestErrorLogger and AccessLogger plumbing a
+	//RequestError returned from a resource method does, instead of writing
+	//straight to w and skipping both.
+	if router.EnableRequestDecompression {
+		body, err := router.CompressReader(r)
+		if err != nil {
+			respondError(ctx, NewRequestError("malformed request encoding"))
+			return
+		}
+		r.Body = body
 	}
-	methodValue.Call([]reflect.Value {reflect.ValueOf(ctx)})
+	//Context.Finder only understands JSON, so a request encoded with a
+	//different negotiated codec is transcoded to JSON up front rather than
+	//teaching every Finder implementation about every registered codec.
+	//protobufCodec can't take part: unlike JSON/YAML/msgpack it has no
+	//generic decode (Unmarshal requires a concrete proto.Message), so a
+	//protobuf body is rejected outright instead of silently handing Finder
+	//raw bytes it would misparse as JSON.
+	if requestCodec := router.codecForContentType(r.Header.Get("Content-Type")); requestCodec.ContentType() != defaultCodecMime && r.ContentLength != 0 {
+		if _, ok := requestCodec.(protobufCodec); ok {
+			respondError(ctx, NewRequestError("unsupported request content type: "+requestCodec.ContentType()))
+			return
+		}
+		if body, err := io.ReadAll(r.Body); err == nil && len(body) > 0 {
+			var generic interface{}
+			if err := requestCodec.Unmarshal(body, &generic); err == nil {
+				if reencoded, err := (jsonCodec{}).Marshal(generic); err == nil {
+					body = reencoded
+				}
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+	}
+	ctx.Finder = FinderWithRequest(r)
+	defer ctx.deferredResponse()
+	handler(ctx)
 }
 
 //Get the paths that have been handled by resources.
@@ -171,7 +248,7 @@ func (r *Router) HandledPaths(withBasePath bool) string {
 	if withBasePath {
 		basePath = strings.TrimSuffix(r.BasePath, "/")
 	}
-	for k, _ := range r.methodMap {
+	for k := range r.methodMap {
 		methodPath := strings.Split(k, " ")
 		handeldPath := methodPath[0]+ " " + basePath + methodPath[1]
 		handledPaths = append(handledPaths, handeldPath)
@@ -180,6 +257,17 @@ func (r *Router) HandledPaths(withBasePath bool) string {
 	return strings.Join(handledPaths, "\n")
 }
 
+//SetOnAppError registers fn to be called after a panic is recovered, in
+//addition to the router's own bookkeeping (populating LogEntry.Err/Stack
+//for AccessLogger). Unlike a plain field assignment, this is safe to call
+//at any point in the router's lifetime, including after NewRouter returns:
+//fn is read fresh on every recovered AppError rather than captured once at
+//registration time.
+//Do time consuming work in fn: it will not increase response time because it runs in its own goroutine.
+func (router *Router) SetOnAppError(fn func(AppError, *Context)) {
+	router.userOnAppError = fn
+}
+
 // Construct a Router instance.
 // Then you can set the configuration fields to config the router.
 // Configuration fields applies to a single router, there are also some package level variables
@@ -188,13 +276,40 @@ func (r *Router) HandledPaths(withBasePath bool) string {
 // See documentation about resources at the top of the file.
 func NewRouter(resources ...interface {}) *Router {
 	router := new(Router)
-	router.methodMap = map[string]reflect.Value{}
-	router.gapsMap = map[string][]string{}
+	router.methodMap = map[string]methodEntry{}
 	config := new(Config)
 	config.BasePath = "/"
 	config.InternalErrorLogger = log.New(os.Stderr, "", 0)
 	config.OnNotFound = notFound
+	config.decoders = map[string]DecoderFactory{
+		"gzip":    gzipDecoder,
+		"deflate": flateDecoder,
+	}
+	config.codecs = map[string]Codec{
+		defaultCodecMime:        jsonCodec{},
+		"application/x-yaml":     yamlCodec{},
+		"application/x-msgpack":  msgpackCodec{},
+		"application/x-protobuf": protobufCodec{},
+	}
 	router.Config = config
+	config.onAppError = func(appErr AppError, ctx *Context) {
+		ctx.lastErr = appErr
+		if _, ok := appErr.(InternalError); ok {
+			ctx.lastStack = captureStack(4)
+		}
+		if router.userOnAppError != nil {
+			go router.userOnAppError(appErr, ctx)
+		}
+	}
+	router.register("", nil, resources...)
+	router.build()
+	return router
+}
+
+//register builds the handled paths for resources the same way NewRouter does,
+//prefixing them with prefix and attaching mw to every registered method.
+//It backs both NewRouter and SubRouter.Mount.
+func (router *Router) register(prefix string, mw []Middleware, resources ...interface {}) {
 	for _, v := range resources {
 		resType := reflect.TypeOf(v)
 		resValue := reflect.ValueOf(v)
@@ -203,15 +318,23 @@ func NewRouter(resources ...interface {}) *Router {
 		resNameSnakeLen := len(resNameSnake)
 		var isIdResource bool
 		var gap string
+		var gaps []string
 		if resNameSnakeLen > 3 && resNameSnake[resNameSnakeLen - 3:] == "_id" {
 			resNameSnake = resNameSnake[:resNameSnakeLen - 3]
 			resNameSnake += "/:id"
 			isIdResource = true
 		}else if resWithGap, ok := v.(ResourceWithGap); ok{
 			gap = resWithGap.Gap()
-			router.gapsMap[resNameSnake] = strings.Split(gap, "/")
+			gaps = strings.Split(gap, "/")
 			resNameSnake += "/" + gap
 		}
+		if prefix != "" {
+			resNameSnake = prefix + "/" + resNameSnake
+		}
+		resMiddleware := mw
+		if resWithMw, ok := v.(ResourceWithMiddleware); ok {
+			resMiddleware = append(append([]Middleware{}, mw...), resWithMw.Middleware()...)
+		}
 		for i := 0; i < resType.NumMethod(); i++ {
 			methodType := resType.Method(i)
 			if !validateMethod(&methodType) {
@@ -250,10 +373,17 @@ func NewRouter(resources ...interface {}) *Router {
 				methodName = methodName[1:]
 			}
 			path := httpMethod + " /" + resNameSnake + methodName
-			router.methodMap[path] = methodValue
+			router.methodMap[path] = methodEntry{
+				value:            methodValue,
+				middleware:       resMiddleware,
+				resource:         v,
+				resourceTypeName: resName,
+				methodName:       methodType.Name,
+				hasId:            isIdResource || isIdMethod,
+				gaps:             gaps,
+			}
 		}
 	}
-	return router
 }
 
 var contextType = reflect.TypeOf(new(Context))
@@ -290,54 +420,12 @@ func convertName(name string) string {
 func notFound(w http.ResponseWriter, r *http.Request) {
 	var response Response
 	response.Error = "NotFound"
-	jsonbytes, _ := json.Marshal(response)
+	codec := negotiateCodecFrom(defaultCodecs, r.Header.Get("Accept"))
+	data, _ := codec.Marshal(response)
+	w.Header().Set("Content-Type", codec.ContentType()+"; charset=utf-8")
 	w.WriteHeader(NotFoundStatusCode)
-	w.Write(jsonbytes)
+	w.Write(data)
 }
 
-//This is an implementation of HandleCORS function to allow all cross domain request.
-func AllowCORS(r *http.Request, responseHeader http.Header) bool {
-	responseHeader.Add("Access-Control-Allow-Origin", "*")
-	if r.Method == "OPTIONS" {
-		return false
-	}
-	return true
-}
-
-func (r *Router) resolvePath(method string, rawPath string) (path string, id int64, segments []string, gaps []string) {
-	segments = strings.Split(rawPath, "/")
-	httpMethod := "GET"
-	switch method{
-	case "POST", "DETETE", "PUT":
-		httpMethod = method
-	}
-	path = httpMethod + " /" + segments[0]
-	seg1 := ""
-	if len(segments) >= 2 {
-		seg1 = segments[1]
-	}
-	id, err := strconv.ParseInt(seg1, 10, 64)
-	if err == nil {
-		path += "/:id"
-		if len(segments) > 2 && segments[2] != "" {
-			path += "/" + segments[2]
-		}
-	}else{
-		gaps = r.gapsMap[segments[0]]
-		if gaps != nil {
-			path += "/" + strings.Join(gaps, "/")
-		}
-		methodIndex := len(gaps) + 1
-		if len(segments) > methodIndex && segments[methodIndex] != "" {
-			path += "/" + segments[methodIndex]
-		}
-		nextIndex := methodIndex + 1
-		if len(segments) > nextIndex && segments[nextIndex] != "" {
-			id, err = strconv.ParseInt(segments[nextIndex], 10, 64)
-			if err == nil {
-				path += "/:id"
-			}
-		}
-	}
-	return
-}
+//AllowAllOrigins is a CORSPolicy.AllowedOrigins value that allows any origin.
+var AllowAllOrigins = []string{"*"}
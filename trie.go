@@ -0,0 +1,226 @@
+package jas
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type paramKind int
+
+const (
+	paramString paramKind = iota
+	paramInt
+	paramUUID
+	paramRegex
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+//node is one segment of the routing trie. static holds literal-segment
+//children, param the (at most one) named-parameter child tried when no
+//static child matches, and catchName/catchHandlers a trailing "*name"
+//wildcard that swallows the rest of the path.
+type node struct {
+	static map[string]*node
+
+	param      *node
+	paramName  string
+	paramKind  paramKind
+	paramRegex *regexp.Regexp
+
+	catchName     string
+	catchHandlers map[string]Handler
+	catchPolicies map[string]CORSPolicy
+
+	handlers map[string]Handler
+	policies map[string]CORSPolicy
+}
+
+func newNode() *node {
+	return &node{static: map[string]*node{}, handlers: map[string]Handler{}, policies: map[string]CORSPolicy{}}
+}
+
+//insert registers handler for method+pattern, creating trie nodes as needed.
+//pattern segments may be a literal ("users"), a named capture (":id",
+//defaulting to an integer constraint the way the resource conventions
+//always have, or ":name" for a plain string capture), a typed/regex capture
+//("{id:int}", "{id:uuid}", "{slug:[a-z-]+}") or a trailing catch-all
+//("*rest"), which must be the last segment. policy is the CORS policy that
+//applies to this method+pattern, resolved at registration time.
+func (root *node) insert(method, pattern string, handler Handler, policy CORSPolicy) {
+	cur := root
+	segments := splitPath(pattern)
+	for _, seg := range segments {
+		if seg == "" {
+			continue
+		}
+		if strings.HasPrefix(seg, "*") {
+			cur.catchName = seg[1:]
+			if cur.catchHandlers == nil {
+				cur.catchHandlers = map[string]Handler{}
+				cur.catchPolicies = map[string]CORSPolicy{}
+			}
+			cur.catchHandlers[method] = handler
+			cur.catchPolicies[method] = policy
+			return
+		}
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "{") {
+			name, kind, re := parseParamToken(seg)
+			if cur.param == nil {
+				cur.param = newNode()
+				cur.param.paramName = name
+				cur.param.paramKind = kind
+				cur.param.paramRegex = re
+			}
+			cur = cur.param
+		} else {
+			child, ok := cur.static[seg]
+			if !ok {
+				child = newNode()
+				cur.static[seg] = child
+			}
+			cur = child
+		}
+	}
+	cur.handlers[method] = handler
+	cur.policies[method] = policy
+}
+
+//parseParamToken parses ":id", ":name", "{id:int}", "{id:uuid}" and
+//"{slug:[a-z-]+}" style tokens. A bare ":id" is typed as an integer to
+//match the existing "/:id" path-segment convention; any other bare
+//":name" is an untyped string capture.
+func parseParamToken(seg string) (name string, kind paramKind, re *regexp.Regexp) {
+	if strings.HasPrefix(seg, ":") {
+		name = seg[1:]
+		if name == "id" {
+			return name, paramInt, nil
+		}
+		return name, paramString, nil
+	}
+	inner := strings.TrimSuffix(strings.TrimPrefix(seg, "{"), "}")
+	parts := strings.SplitN(inner, ":", 2)
+	name = parts[0]
+	if len(parts) == 1 {
+		return name, paramString, nil
+	}
+	switch parts[1] {
+	case "int":
+		return name, paramInt, nil
+	case "uuid":
+		return name, paramUUID, nil
+	case "string":
+		return name, paramString, nil
+	default:
+		return name, paramRegex, regexp.MustCompile("^" + parts[1] + "$")
+	}
+}
+
+func (n *node) matchesParam(seg string) bool {
+	switch n.paramKind {
+	case paramInt:
+		_, err := strconv.ParseInt(seg, 10, 64)
+		return err == nil
+	case paramUUID:
+		return uuidPattern.MatchString(seg)
+	case paramRegex:
+		return n.paramRegex.MatchString(seg)
+	default:
+		return seg != ""
+	}
+}
+
+//match walks segments from the trie root and returns the handler
+//registered for method at the matched leaf, populating params with any
+//named captures (including a matched catch-all) along the way.
+func (root *node) match(method string, segments []string, params map[string]string) (Handler, bool) {
+	if len(segments) == 0 {
+		h, ok := root.handlers[method]
+		return h, ok
+	}
+	seg, rest := segments[0], segments[1:]
+	if child, ok := root.static[seg]; ok {
+		if h, ok := child.match(method, rest, params); ok {
+			return h, true
+		}
+	}
+	if root.param != nil && root.param.matchesParam(seg) {
+		params[root.param.paramName] = seg
+		if h, ok := root.param.match(method, rest, params); ok {
+			return h, true
+		}
+		delete(params, root.param.paramName)
+	}
+	if root.catchHandlers != nil {
+		if h, ok := root.catchHandlers[method]; ok {
+			params[root.catchName] = strings.Join(segments, "/")
+			return h, true
+		}
+	}
+	return nil, false
+}
+
+//matchAny walks segments the same way match does but ignores the HTTP
+//method, returning the node that owns whatever is registered there. It's
+//used to answer CORS preflight requests, where the actual verb being asked
+//about ("Access-Control-Request-Method") may never itself be registered.
+func (root *node) matchAny(segments []string, params map[string]string) (*node, bool) {
+	if len(segments) == 0 {
+		if len(root.handlers) > 0 {
+			return root, true
+		}
+		return nil, false
+	}
+	seg, rest := segments[0], segments[1:]
+	if child, ok := root.static[seg]; ok {
+		if n, ok := child.matchAny(rest, params); ok {
+			return n, true
+		}
+	}
+	if root.param != nil && root.param.matchesParam(seg) {
+		params[root.param.paramName] = seg
+		if n, ok := root.param.matchAny(rest, params); ok {
+			return n, true
+		}
+		delete(params, root.param.paramName)
+	}
+	if root.catchHandlers != nil {
+		params[root.catchName] = strings.Join(segments, "/")
+		return &node{handlers: root.catchHandlers, policies: root.catchPolicies}, true
+	}
+	return nil, false
+}
+
+//allowedMethods returns the sorted set of HTTP methods actually registered
+//at n, e.g. for the Access-Control-Allow-Methods preflight header.
+func (n *node) allowedMethods() []string {
+	methods := make([]string, 0, len(n.handlers))
+	for m := range n.handlers {
+		methods = append(methods, m)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+//dispatchMethod maps an HTTP method to the trie method it's routed as. Per
+//the package doc comment, only "POST", "PUT" and "DELETE" get their own
+//resource methods; everything else, including "HEAD" and "OPTIONS", is
+//routed to the same handler as "GET".
+func dispatchMethod(method string) string {
+	switch method {
+	case "POST", "PUT", "DELETE":
+		return method
+	default:
+		return "GET"
+	}
+}
+
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
@@ -0,0 +1,118 @@
+package jas
+
+import "testing"
+
+func handlerFor(t *testing.T, name string) Handler {
+	return func(ctx *Context) {
+		t.Helper()
+		t.Errorf("handler %q was not expected to run", name)
+	}
+}
+
+func TestTrieMatchBacktracksFromStaticToParam(t *testing.T) {
+	root := newNode()
+	root.insert("GET", "/users/:id", handlerFor(t, "users/:id"), CORSPolicy{})
+	root.insert("GET", "/users/search", handlerFor(t, "users/search"), CORSPolicy{})
+
+	params := map[string]string{}
+	if _, ok := root.match("GET", []string{"users", "search"}, params); !ok {
+		t.Fatal("expected the static \"search\" segment to win over the :id param")
+	}
+	if len(params) != 0 {
+		t.Fatalf("static match should not populate params, got %v", params)
+	}
+
+	params = map[string]string{}
+	if _, ok := root.match("GET", []string{"users", "42"}, params); !ok {
+		t.Fatal("expected \"42\" to fall back to the :id param since it isn't a static child")
+	}
+	if params["id"] != "42" {
+		t.Fatalf("expected params[\"id\"] = \"42\", got %v", params)
+	}
+
+	params = map[string]string{}
+	if _, ok := root.match("GET", []string{"users", "search", "extra"}, params); ok {
+		t.Fatal("expected no match for a path with a trailing extra segment")
+	}
+}
+
+func TestTrieMatchBacktracksWhenDeeperStaticFails(t *testing.T) {
+	root := newNode()
+	root.insert("GET", "/orgs/:org/repos/:repo", handlerFor(t, "orgs/:org/repos/:repo"), CORSPolicy{})
+	root.insert("GET", "/orgs/acme/billing", handlerFor(t, "orgs/acme/billing"), CORSPolicy{})
+
+	// "acme" matches the static child, but "repos" doesn't match "billing"
+	// one level down, so match must backtrack and retry via the :org param.
+	params := map[string]string{}
+	handler, ok := root.match("GET", []string{"orgs", "acme", "repos", "42"}, params)
+	if !ok || handler == nil {
+		t.Fatal("expected backtracking from the static \"acme\" child to the :org param to still find a match")
+	}
+	if params["org"] != "acme" || params["repo"] != "42" {
+		t.Fatalf("expected org=acme repo=42, got %v", params)
+	}
+}
+
+func TestTrieMatchesParamKinds(t *testing.T) {
+	cases := []struct {
+		name    string
+		pattern string
+		path    string
+		wantOk  bool
+		params  map[string]string
+	}{
+		{"bare :id requires an integer", "/things/:id", "/things/42", true, map[string]string{"id": "42"}},
+		{"bare :id rejects non-integers", "/things/:id", "/things/abc", false, nil},
+		{"bare :name accepts any non-empty segment", "/tags/:name", "/tags/go-lang", true, map[string]string{"name": "go-lang"}},
+		{"{id:uuid} requires a UUID", "/widgets/{id:uuid}", "/widgets/550e8400-e29b-41d4-a716-446655440000", true, map[string]string{"id": "550e8400-e29b-41d4-a716-446655440000"}},
+		{"{id:uuid} rejects a plain integer", "/widgets/{id:uuid}", "/widgets/42", false, nil},
+		{"{slug:[a-z-]+} enforces the regex", "/posts/{slug:[a-z-]+}", "/posts/hello-world", true, map[string]string{"slug": "hello-world"}},
+		{"{slug:[a-z-]+} rejects what the regex excludes", "/posts/{slug:[a-z-]+}", "/posts/Hello_World", false, nil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := newNode()
+			root.insert("GET", c.pattern, handlerFor(t, c.pattern), CORSPolicy{})
+			params := map[string]string{}
+			_, ok := root.match("GET", splitPath(c.path), params)
+			if ok != c.wantOk {
+				t.Fatalf("match(%q) ok = %v, want %v", c.path, ok, c.wantOk)
+			}
+			for k, v := range c.params {
+				if params[k] != v {
+					t.Errorf("params[%q] = %q, want %q", k, params[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestTrieCatchAllSwallowsRemainingSegments(t *testing.T) {
+	root := newNode()
+	root.insert("GET", "/files/*path", handlerFor(t, "files/*path"), CORSPolicy{})
+
+	params := map[string]string{}
+	if _, ok := root.match("GET", []string{"files", "a", "b", "c.txt"}, params); !ok {
+		t.Fatal("expected the catch-all to match any number of trailing segments")
+	}
+	if params["path"] != "a/b/c.txt" {
+		t.Fatalf("expected params[\"path\"] = \"a/b/c.txt\", got %v", params)
+	}
+}
+
+func TestDispatchMethod(t *testing.T) {
+	cases := map[string]string{
+		"GET":     "GET",
+		"POST":    "POST",
+		"PUT":     "PUT",
+		"DELETE":  "DELETE",
+		"HEAD":    "GET",
+		"OPTIONS": "GET",
+		"PATCH":   "GET",
+	}
+	for method, want := range cases {
+		if got := dispatchMethod(method); got != want {
+			t.Errorf("dispatchMethod(%q) = %q, want %q", method, got, want)
+		}
+	}
+}